@@ -0,0 +1,98 @@
+package dynamichost
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/cel-go/cel"
+)
+
+// celRequestEnv returns the cel.Env used to compile operator-supplied
+// `match` expressions. It exposes a single `req` map variable with the
+// fields below; no placeholders or matcher functions (such as host() or
+// header()) are declared, so expressions are written against `req` only:
+//
+//	req.host, req.method, req.uri, req.remote_ip
+//	req.header["Name"]
+//	req.tls.sni
+func celRequestEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("req", cel.MapType(cel.StringType, cel.DynType)),
+	)
+}
+
+// compileMatchExpr compiles expr against celRequestEnv into a reusable
+// cel.Program. It's called once per refresh (not per request), so a
+// malformed expression surfaces as a refresh error rather than a
+// per-request panic.
+func compileMatchExpr(expr string) (cel.Program, error) {
+	env, err := celRequestEnv()
+	if err != nil {
+		return nil, fmt.Errorf("building CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compiling match expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("match expression must evaluate to bool, got %s", ast.OutputType())
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("building CEL program: %w", err)
+	}
+
+	return prg, nil
+}
+
+// evalMatchExpr runs prg against req and reports the boolean result. Eval
+// errors (e.g. indexing req.header with a name the request doesn't carry)
+// are treated as non-matches
+// rather than propagated, consistent with how Match() fails closed
+// elsewhere in this package.
+func evalMatchExpr(prg cel.Program, req *http.Request) bool {
+	out, _, err := prg.Eval(map[string]any{"req": celRequestVars(req)})
+	if err != nil {
+		return false
+	}
+
+	matched, ok := out.Value().(bool)
+	return ok && matched
+}
+
+// celRequestVars builds the `req` activation map for a single request.
+func celRequestVars(req *http.Request) map[string]any {
+	sni := ""
+	if req.TLS != nil {
+		sni = req.TLS.ServerName
+	}
+
+	headers := make(map[string]string, len(req.Header))
+	for name := range req.Header {
+		headers[name] = req.Header.Get(name)
+	}
+
+	return map[string]any{
+		"host":      req.Host,
+		"method":    req.Method,
+		"uri":       req.URL.RequestURI(),
+		"remote_ip": remoteIP(req),
+		"header":    headers,
+		"tls": map[string]any{
+			"sni": sni,
+		},
+	}
+}
+
+// remoteIP strips the port from req.RemoteAddr, falling back to the raw
+// value if it isn't in host:port form.
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
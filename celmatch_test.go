@@ -0,0 +1,52 @@
+package dynamichost
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompileMatchExpr(t *testing.T) {
+	prg, err := compileMatchExpr(`req.host.endsWith(".tenant.example")`)
+	if err != nil {
+		t.Fatalf("compileMatchExpr() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://acme.tenant.example/", nil)
+	if !evalMatchExpr(prg, req) {
+		t.Error("expected match for host ending in .tenant.example")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if evalMatchExpr(prg, req) {
+		t.Error("expected no match for unrelated host")
+	}
+}
+
+func TestCompileMatchExpr_InvalidExpr(t *testing.T) {
+	if _, err := compileMatchExpr("req.host +"); err == nil {
+		t.Fatal("expected error for malformed expression")
+	}
+}
+
+func TestCompileMatchExpr_NonBoolResult(t *testing.T) {
+	if _, err := compileMatchExpr("req.host"); err == nil {
+		t.Fatal("expected error for expression that doesn't evaluate to bool")
+	}
+}
+
+func TestEvalMatchExpr_HeaderAndTLS(t *testing.T) {
+	prg, err := compileMatchExpr(`req.header["X-Tenant-Id"] == "acme" && req.tls.sni == "acme.example"`)
+	if err != nil {
+		t.Fatalf("compileMatchExpr() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("X-Tenant-Id", "acme")
+	req.TLS = &tls.ConnectionState{ServerName: "acme.example"}
+
+	if !evalMatchExpr(prg, req) {
+		t.Error("expected match on header and SNI")
+	}
+}
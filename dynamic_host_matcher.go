@@ -1,34 +1,50 @@
 // Package dynamichost provides a Caddy HTTP matcher that dynamically loads
-// host lists from HTTP endpoints. This is particularly useful for SaaS
+// host lists from a pluggable source. This is particularly useful for SaaS
 // applications where the list of valid hosts changes frequently.
 //
 // Example usage in Caddyfile:
 //
 //	@dynamic_hosts {
 //		dynamic_host {
-//			source https://api.example.com/hosts
+//			source http https://api.example.com/hosts
 //			interval 30s
 //		}
 //	}
 //
-// The source endpoint should return JSON in the format:
+// The source is itself a Caddy module under the
+// "http.matchers.dynamic_host.sources" namespace (see source.go and the
+// source_*.go files for the built-in "http", "file", "dns", "consul" and
+// "etcd" sources), so operators can plug in whatever service-discovery
+// backend they already run instead of standing up a JSON HTTP shim.
 //
-//	{"hosts": ["example.com", "app1.example.com", "*.wildcard.com"]}
+// Sources that implement ConditionalHostSource (such as the built-in "http"
+// source, via ETag/Last-Modified) can report that nothing has changed,
+// which avoids needless matcher rebuilds. When refreshes start failing, the
+// last known good host list keeps being served — for up to stale_ok, after
+// which Match fails closed — while the refresh interval backs off
+// exponentially up to max_backoff.
+//
+// Sources that implement ExpressionHostSource can additionally supply a CEL
+// match expression alongside the host list (see celmatch.go). When one is
+// present, Match evaluates it instead of the host-list matcher, which lets
+// operators express rules a host glob can't, such as requiring a specific
+// TLS SNI, header, or path prefix.
 package dynamichost
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net/http"
-	"net/url"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/certmagic"
+	"github.com/google/cel-go/cel"
 	"go.uber.org/zap"
 )
 
@@ -37,21 +53,47 @@ func init() {
 }
 
 // Module implements a Caddy HTTP request matcher that dynamically loads
-// host lists from HTTP endpoints.
+// host lists from a pluggable source.
 type Module struct {
-	// Source is the HTTP/HTTPS URL endpoint that provides the JSON host list.
-	Source string `json:"source,omitempty"`
+	// SourceRaw is the configuration for the HostSource module that
+	// provides the host list, e.g. {"source": "http", "url": "..."}.
+	SourceRaw json.RawMessage `json:"source,omitempty" caddy:"namespace=http.matchers.dynamic_host.sources inline_key=source"`
 
 	// Interval specifies how often to refresh the host list from the source.
 	// Default: 30s if not specified.
 	Interval caddy.Duration `json:"interval,omitempty"`
 
-	ctx     caddy.Context
-	u       *url.URL
-	client  *http.Client
-	mu      sync.RWMutex
-	matcher caddyhttp.MatchHost
-	logger  *zap.Logger
+	// StaleOk specifies how long a stale (last known good) host list may
+	// keep being served after refreshes start failing. Once exceeded, Match
+	// fails closed (returns false) until a refresh succeeds again. Zero
+	// means serve stale data indefinitely.
+	StaleOk caddy.Duration `json:"stale_ok,omitempty"`
+
+	// MaxBackoff caps the exponential backoff applied to the refresh
+	// interval while refreshes are failing. Default: 10m.
+	MaxBackoff caddy.Duration `json:"max_backoff,omitempty"`
+
+	// Persist controls whether the host list is cached to caddy.Storage
+	// after each successful refresh, and seeded from that cache on
+	// Provision so Caddy can start serving immediately even if the source
+	// is unreachable at boot. Default: true.
+	Persist *bool `json:"persist,omitempty"`
+
+	// MaxCacheAge discards the persisted cache on Provision if it is older
+	// than this. Zero means the persisted cache is never too old to use.
+	MaxCacheAge caddy.Duration `json:"max_cache_age,omitempty"`
+
+	source      HostSource
+	sourceName  string
+	ctx         caddy.Context
+	mu          sync.RWMutex
+	matcher     caddyhttp.MatchHost
+	matchExpr   cel.Program
+	lastSuccess time.Time
+	backoffN    int
+	logger      *zap.Logger
+	storage     certmagic.Storage
+	cacheKey    string
 }
 
 func (m *Module) CaddyModule() caddy.ModuleInfo {
@@ -62,33 +104,41 @@ func (m *Module) CaddyModule() caddy.ModuleInfo {
 }
 
 func (m *Module) Provision(ctx caddy.Context) error {
-	if m.Source == "" {
-		return fmt.Errorf("dynamic_host matcher: source URL is required")
+	if m.SourceRaw == nil {
+		return fmt.Errorf("dynamic_host matcher: source is required")
 	}
 
-	u, err := url.Parse(m.Source)
+	mod, err := ctx.LoadModule(m, "SourceRaw")
 	if err != nil {
-		return fmt.Errorf("dynamic_host matcher: invalid source URL '%s': %w", m.Source, err)
+		return fmt.Errorf("dynamic_host matcher: loading source module: %w", err)
 	}
-
-	if u.Scheme != "http" && u.Scheme != "https" {
-		return fmt.Errorf("dynamic_host matcher: source URL must use http or https scheme, got '%s'", u.Scheme)
+	source, ok := mod.(HostSource)
+	if !ok {
+		return fmt.Errorf("dynamic_host matcher: configured source does not implement HostSource")
 	}
+	m.source = source
+	m.sourceName = sourceLabel(m.SourceRaw)
+
+	initMetrics(ctx.GetMetricsRegistry())
 
-	m.u = u
-	m.client = &http.Client{Timeout: 5 * time.Second}
 	m.ctx = ctx
 	m.matcher = caddyhttp.MatchHost{}
 	m.logger = ctx.Logger()
+	m.cacheKey = cacheKeyFor(m.SourceRaw)
 
 	if m.Interval == 0 {
 		m.Interval = caddy.Duration(30 * time.Second)
 	}
 
 	m.logger.Info("initializing dynamic host matcher",
-		zap.String("source", m.Source),
 		zap.Duration("interval", time.Duration(m.Interval)))
 
+	if m.persistEnabled() {
+		if err := m.loadPersistedHosts(ctx); err != nil {
+			m.logger.Warn("failed to load persisted host cache", zap.Error(err))
+		}
+	}
+
 	// Initial fetch
 	if err := m.refreshHosts(ctx); err != nil {
 		m.logger.Warn("failed to fetch initial hosts", zap.Error(err))
@@ -100,95 +150,161 @@ func (m *Module) Provision(ctx caddy.Context) error {
 
 func (m *Module) Match(req *http.Request) bool {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.matcher.Match(req)
+	stale := m.StaleOk > 0 && !m.lastSuccess.IsZero() && time.Since(m.lastSuccess) > time.Duration(m.StaleOk)
+	matchExpr := m.matchExpr
+	var matched bool
+	switch {
+	case stale:
+		matched = false
+	case matchExpr != nil:
+		matched = evalMatchExpr(matchExpr, req)
+	default:
+		matched = m.matcher.Match(req)
+	}
+	m.mu.RUnlock()
+
+	result := "no_match"
+	if matched {
+		result = "match"
+	}
+	current.Load().matchTotal.WithLabelValues(result).Inc()
+
+	return matched
 }
 
 func (m *Module) refreshLoop() {
-	ticker := time.NewTicker(time.Duration(m.Interval))
-	defer ticker.Stop()
+	timer := time.NewTimer(time.Duration(m.Interval))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			if err := m.refreshHosts(m.ctx); err != nil {
+		case <-timer.C:
+			err := m.refreshHosts(m.ctx)
+			if err != nil {
 				m.logger.Error("failed to refresh hosts", zap.Error(err))
 			}
+			timer.Reset(m.nextRefreshDelay(err == nil))
 		case <-m.ctx.Done():
 			return
 		}
 	}
 }
 
-// refreshHosts fetches and updates the host list from the source endpoint.
-func (m *Module) refreshHosts(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.u.String(), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+// nextRefreshDelay computes the delay until the next refresh attempt. A
+// successful refresh resets the backoff to the configured Interval; a
+// failed one grows it exponentially up to MaxBackoff, then adds up to 25%
+// random jitter on top.
+func (m *Module) nextRefreshDelay(success bool) time.Duration {
+	base := time.Duration(m.Interval)
 
-	req.Header.Set("User-Agent", "Caddy-Dynamic-Host-Matcher/1.0")
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	resp, err := m.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+	if success {
+		m.backoffN = 0
+		return base
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	maxBackoff := time.Duration(m.MaxBackoff)
+	if maxBackoff == 0 {
+		maxBackoff = 10 * time.Minute
 	}
 
-	var data struct {
-		Hosts []string `json:"hosts"`
+	delay := base * time.Duration(int64(1)<<uint(m.backoffN))
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	} else {
+		m.backoffN++
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
-	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/4 + 1))
+	return delay + jitter
+}
 
-	if len(data.Hosts) == 0 {
-		return fmt.Errorf("empty host list returned")
-	}
+// refreshHosts fetches and updates the host list from the configured
+// source. On failure, m.matcher is left untouched so the last known good
+// list keeps being served (subject to StaleOk).
+func (m *Module) refreshHosts(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { observeRefresh(m.sourceName, start, err) }()
 
-	// Validate hosts
-	for _, host := range data.Hosts {
-		if strings.TrimSpace(host) == "" {
-			return fmt.Errorf("invalid empty host in list")
-		}
+	var hosts []string
+	var notModified bool
+
+	if cs, ok := m.source.(ConditionalHostSource); ok {
+		hosts, notModified, err = cs.GetHostsIfModified(ctx)
+	} else {
+		hosts, err = m.source.GetHosts(ctx)
+	}
+	if err != nil {
+		return err
 	}
 
+	metrics := current.Load()
+
 	m.mu.Lock()
-	defer m.mu.Unlock()
+
+	if notModified {
+		m.lastSuccess = time.Now()
+		metrics.lastSuccessTime.WithLabelValues(m.sourceName).Set(float64(m.lastSuccess.Unix()))
+		persisted := []string(m.matcher)
+		m.mu.Unlock()
+		m.logger.Debug("host list not modified since last refresh")
+		m.persistHosts(persisted)
+		return nil
+	}
 
 	oldCount := len(m.matcher)
-	m.matcher = data.Hosts
+	newMatcher := caddyhttp.MatchHost(hosts)
 
-	if err := m.matcher.Provision(m.ctx); err != nil {
+	if err = newMatcher.Provision(m.ctx); err != nil {
+		m.mu.Unlock()
 		return fmt.Errorf("failed to provision matcher: %w", err)
 	}
 
+	var matchExpr cel.Program
+	if es, ok := m.source.(ExpressionHostSource); ok {
+		if expr := es.MatchExpr(); expr != "" {
+			if matchExpr, err = compileMatchExpr(expr); err != nil {
+				m.mu.Unlock()
+				return fmt.Errorf("failed to compile match expression: %w", err)
+			}
+		}
+	}
+	m.matcher = newMatcher
+	m.matchExpr = matchExpr
+	m.lastSuccess = time.Now()
+	metrics.lastSuccessTime.WithLabelValues(m.sourceName).Set(float64(m.lastSuccess.Unix()))
+
+	metrics.listSize.WithLabelValues(m.sourceName).Set(float64(len(hosts)))
+	m.mu.Unlock()
+
 	m.logger.Info("updated host list",
-		zap.Int("count", len(data.Hosts)),
+		zap.Int("count", len(hosts)),
 		zap.Int("previous", oldCount))
 
+	m.persistHosts(hosts)
+
 	return nil
 }
 
+// UnmarshalCaddyfile sets up the module from Caddyfile tokens:
+//
+//	dynamic_host {
+//		source <name> ...
+//		interval <duration>
+//	}
 func (m *Module) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 	d.Next() // consume directive name
 
 	for d.NextBlock(0) {
 		switch d.Val() {
 		case "source":
-			if !d.NextArg() {
-				return d.ArgErr()
-			}
-			m.Source = d.Val()
-
-			if _, err := url.Parse(m.Source); err != nil {
-				return d.Errf("invalid source URL: %v", err)
+			raw, err := parseCaddyfileSource(d)
+			if err != nil {
+				return err
 			}
+			m.SourceRaw = raw
 
 		case "interval":
 			if !d.NextArg() {
@@ -209,12 +325,57 @@ func (m *Module) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 
 			m.Interval = caddy.Duration(interval)
 
+		case "stale_ok":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			staleOk, err := caddy.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid stale_ok: %v", err)
+			}
+			m.StaleOk = caddy.Duration(staleOk)
+
+		case "max_backoff":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			maxBackoff, err := caddy.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid max_backoff: %v", err)
+			}
+			m.MaxBackoff = caddy.Duration(maxBackoff)
+
+		case "persist":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			switch d.Val() {
+			case "true":
+				persist := true
+				m.Persist = &persist
+			case "false":
+				persist := false
+				m.Persist = &persist
+			default:
+				return d.Errf("persist must be 'true' or 'false', got '%s'", d.Val())
+			}
+
+		case "max_cache_age":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			maxCacheAge, err := caddy.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid max_cache_age: %v", err)
+			}
+			m.MaxCacheAge = caddy.Duration(maxCacheAge)
+
 		default:
 			return d.Errf("unrecognized parameter '%s'", d.Val())
 		}
 	}
 
-	if m.Source == "" {
+	if m.SourceRaw == nil {
 		return d.Err("source parameter is required")
 	}
 
@@ -2,9 +2,9 @@ package dynamichost
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -12,6 +12,7 @@ import (
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -26,7 +27,7 @@ func TestModule_UnmarshalCaddyfile(t *testing.T) {
 		{
 			name: "valid config",
 			input: `dynamic_host {
-				source https://api.example.com/hosts
+				source http https://api.example.com/hosts
 				interval 30s
 			}`,
 			wantSrc: "https://api.example.com/hosts",
@@ -39,10 +40,17 @@ func TestModule_UnmarshalCaddyfile(t *testing.T) {
 			}`,
 			wantErr: true,
 		},
+		{
+			name: "unrecognized source",
+			input: `dynamic_host {
+				source bogus https://api.example.com/hosts
+			}`,
+			wantErr: true,
+		},
 		{
 			name: "invalid interval",
 			input: `dynamic_host {
-				source https://api.example.com/hosts
+				source http https://api.example.com/hosts
 				interval invalid
 			}`,
 			wantErr: true,
@@ -50,7 +58,7 @@ func TestModule_UnmarshalCaddyfile(t *testing.T) {
 		{
 			name: "interval too short",
 			input: `dynamic_host {
-				source https://api.example.com/hosts
+				source http https://api.example.com/hosts
 				interval 500ms
 			}`,
 			wantErr: true,
@@ -69,8 +77,14 @@ func TestModule_UnmarshalCaddyfile(t *testing.T) {
 			}
 
 			if !tt.wantErr {
-				if m.Source != tt.wantSrc {
-					t.Errorf("Source = %v, want %v", m.Source, tt.wantSrc)
+				if m.SourceRaw == nil {
+					t.Fatal("expected SourceRaw to be set")
+				}
+				var src struct {
+					URL string `json:"url"`
+				}
+				if err := json.Unmarshal(m.SourceRaw, &src); err != nil || src.URL != tt.wantSrc {
+					t.Errorf("source url = %q (err %v), want %v", src.URL, err, tt.wantSrc)
 				}
 				if time.Duration(m.Interval) != tt.wantInt {
 					t.Errorf("Interval = %v, want %v", time.Duration(m.Interval), tt.wantInt)
@@ -142,25 +156,26 @@ func TestModule_RefreshHosts(t *testing.T) {
 	}
 }
 
-// newTestModule creates a properly initialized module for testing
+// newTestModule creates a properly initialized module for testing, wired up
+// with an HTTPSource pointed at sourceURL.
 func newTestModule(t *testing.T, sourceURL string) *Module {
 	t.Helper()
 
-	m := &Module{
-		Source: sourceURL,
+	src := &HTTPSource{URL: sourceURL}
+	if err := src.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("failed to provision test source: %v", err)
 	}
 
-	u, err := url.Parse(sourceURL)
-	if err != nil {
-		t.Fatalf("Failed to parse test URL: %v", err)
-	}
+	initMetrics(prometheus.NewRegistry())
 
-	m.u = u
-	m.client = &http.Client{Timeout: 5 * time.Second}
-	m.logger = zap.NewNop()
-	m.mu = sync.RWMutex{}
-	m.matcher = caddyhttp.MatchHost{}
-	m.ctx = caddy.Context{}
+	m := &Module{
+		source:     src,
+		sourceName: "http",
+		logger:     zap.NewNop(),
+		mu:         sync.RWMutex{},
+		matcher:    caddyhttp.MatchHost{},
+		ctx:        caddy.Context{},
+	}
 
 	return m
 }
@@ -0,0 +1,111 @@
+package dynamichost
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsSet groups the metric instances registered against a single
+// registry, so the whole set can be swapped atomically instead of
+// reassigning each pointer individually under a lock that readers don't
+// also take.
+type metricsSet struct {
+	refreshTotal    *prometheus.CounterVec
+	refreshDuration *prometheus.HistogramVec
+	listSize        *prometheus.GaugeVec
+	lastSuccessTime *prometheus.GaugeVec
+	matchTotal      *prometheus.CounterVec
+}
+
+// Metrics are (re-)created and registered whenever initMetrics is called
+// with a registry other than the one it last registered against (mirroring
+// modules/caddyhttp/reverseproxy/metrics.go), since Prometheus panics if
+// the same metric is registered twice against the same registry. In
+// production this makes initMetrics a no-op after the first Module using a
+// given *caddy.Context's metrics registry is provisioned; in tests, each
+// call with a fresh prometheus.NewRegistry() gets its own metric instances
+// instead of accumulating state from earlier tests.
+//
+// current is read by Match/observeRefresh/refreshHosts with no lock, which
+// can race against a concurrent initMetrics from a config reload (the old
+// Module's goroutines keep running until ctx.Done()), so it's swapped via
+// atomic.Pointer rather than plain package vars.
+var (
+	metricsMu       sync.Mutex
+	metricsRegistry *prometheus.Registry
+	current         atomic.Pointer[metricsSet]
+)
+
+func initMetrics(registry *prometheus.Registry) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	if metricsRegistry == registry {
+		return
+	}
+	metricsRegistry = registry
+
+	set := &metricsSet{
+		refreshTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "dynamic_host",
+			Name:      "refresh_total",
+			Help:      "Count of host list refresh attempts, by source and status.",
+		}, []string{"source", "status"}),
+
+		refreshDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: "dynamic_host",
+			Name:      "refresh_duration_seconds",
+			Help:      "Time spent refreshing the host list from the source.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"source"}),
+
+		listSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "dynamic_host",
+			Name:      "list_size",
+			Help:      "Number of hosts in the most recently refreshed list.",
+		}, []string{"source"}),
+
+		lastSuccessTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "dynamic_host",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful refresh.",
+		}, []string{"source"}),
+
+		matchTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "dynamic_host",
+			Name:      "match_total",
+			Help:      "Count of Match calls, by result.",
+		}, []string{"result"}),
+	}
+
+	registry.MustRegister(set.refreshTotal, set.refreshDuration, set.listSize, set.lastSuccessTime, set.matchTotal)
+	current.Store(set)
+}
+
+// sourceLabel extracts the "source" inline key (e.g. "http", "file", "dns")
+// from raw so it can be used as a metrics label without needing a reference
+// to the loaded HostSource instance.
+func sourceLabel(raw json.RawMessage) string {
+	var tagged struct {
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal(raw, &tagged); err != nil || tagged.Source == "" {
+		return "unknown"
+	}
+	return tagged.Source
+}
+
+// observeRefresh records the outcome of a single refresh attempt.
+func observeRefresh(source string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	m := current.Load()
+	m.refreshTotal.WithLabelValues(source, status).Inc()
+	m.refreshDuration.WithLabelValues(source).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,82 @@
+package dynamichost
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRefreshHosts_Metrics(t *testing.T) {
+	initMetrics(prometheus.NewRegistry())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hosts": ["example.com", "test.com"]}`))
+	}))
+	defer server.Close()
+
+	m := newTestModule(t, server.URL)
+
+	if err := m.refreshHosts(context.Background()); err != nil {
+		t.Fatalf("refreshHosts() error = %v", err)
+	}
+
+	if got := testutil.ToFloat64(current.Load().refreshTotal.WithLabelValues("http", "success")); got != 1 {
+		t.Errorf("refresh_total{success} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(current.Load().listSize.WithLabelValues("http")); got != 2 {
+		t.Errorf("list_size = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(current.Load().lastSuccessTime.WithLabelValues("http")); got == 0 {
+		t.Errorf("last_success_timestamp_seconds = %v, want nonzero", got)
+	}
+
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server2.Close()
+	m2 := newTestModule(t, server2.URL)
+
+	if err := m2.refreshHosts(context.Background()); err == nil {
+		t.Fatal("expected error from 404 response")
+	}
+	if got := testutil.ToFloat64(current.Load().refreshTotal.WithLabelValues("http", "failure")); got != 1 {
+		t.Errorf("refresh_total{failure} = %v, want 1", got)
+	}
+}
+
+func TestMatch_Metrics(t *testing.T) {
+	initMetrics(prometheus.NewRegistry())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hosts": ["example.com"]}`))
+	}))
+	defer server.Close()
+
+	m := newTestModule(t, server.URL)
+	if err := m.refreshHosts(context.Background()); err != nil {
+		t.Fatalf("refreshHosts() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+	before := testutil.ToFloat64(current.Load().matchTotal.WithLabelValues("match"))
+	m.Match(req)
+	if got := testutil.ToFloat64(current.Load().matchTotal.WithLabelValues("match")); got != before+1 {
+		t.Errorf("match_total{match} = %v, want %v", got, before+1)
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "http://other.com/", nil)
+	req2 = req2.WithContext(context.WithValue(req2.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+	before = testutil.ToFloat64(current.Load().matchTotal.WithLabelValues("no_match"))
+	m.Match(req2)
+	if got := testutil.ToFloat64(current.Load().matchTotal.WithLabelValues("no_match")); got != before+1 {
+		t.Errorf("match_total{no_match} = %v, want %v", got, before+1)
+	}
+}
@@ -0,0 +1,109 @@
+package dynamichost
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHTTPSource_GetHostsIfModified(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hosts": ["example.com"]}`))
+	}))
+	defer server.Close()
+
+	s := &HTTPSource{URL: server.URL}
+	if err := s.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	hosts, notModified, err := s.GetHostsIfModified(context.Background())
+	if err != nil {
+		t.Fatalf("GetHostsIfModified() error = %v", err)
+	}
+	if notModified {
+		t.Fatal("expected first fetch to not be notModified")
+	}
+	if len(hosts) != 1 || hosts[0] != "example.com" {
+		t.Errorf("hosts = %v, want [example.com]", hosts)
+	}
+
+	hosts, notModified, err = s.GetHostsIfModified(context.Background())
+	if err != nil {
+		t.Fatalf("GetHostsIfModified() second call error = %v", err)
+	}
+	if !notModified {
+		t.Error("expected second fetch to be notModified")
+	}
+	if hosts != nil {
+		t.Errorf("hosts = %v, want nil on 304", hosts)
+	}
+}
+
+func TestModule_Match_StaleOk(t *testing.T) {
+	m := &Module{
+		StaleOk: caddy.Duration(50 * time.Millisecond),
+		matcher: caddyhttp.MatchHost{"example.com"},
+	}
+	if err := m.matcher.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("matcher.Provision() error = %v", err)
+	}
+	m.lastSuccess = time.Now()
+	initMetrics(prometheus.NewRegistry())
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+	if !m.Match(req) {
+		t.Error("expected match while within stale_ok window")
+	}
+
+	m.lastSuccess = time.Now().Add(-time.Second)
+	if m.Match(req) {
+		t.Error("expected no match once stale_ok window has elapsed")
+	}
+}
+
+func TestModule_nextRefreshDelay(t *testing.T) {
+	m := &Module{
+		Interval:   caddy.Duration(time.Second),
+		MaxBackoff: caddy.Duration(4 * time.Second),
+	}
+
+	if got := m.nextRefreshDelay(true); got != time.Second {
+		t.Errorf("delay after success = %v, want 1s", got)
+	}
+
+	d1 := m.nextRefreshDelay(false)
+	if d1 < time.Second {
+		t.Errorf("first backoff delay = %v, want >= 1s", d1)
+	}
+
+	d2 := m.nextRefreshDelay(false)
+	if d2 < d1 {
+		t.Errorf("second backoff delay = %v, want >= first delay %v", d2, d1)
+	}
+
+	for i := 0; i < 10; i++ {
+		if d := m.nextRefreshDelay(false); d > 5*time.Second {
+			t.Errorf("backoff delay %v exceeded max_backoff+jitter bound", d)
+		}
+	}
+
+	if got := m.nextRefreshDelay(true); got != time.Second {
+		t.Errorf("delay after recovery = %v, want 1s", got)
+	}
+}
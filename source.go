@@ -0,0 +1,107 @@
+package dynamichost
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+// HostSource is implemented by the modules registered under the
+// "http.matchers.dynamic_host.sources" namespace. Each source knows how to
+// retrieve the current list of hosts from a particular backend (a JSON HTTP
+// endpoint, a local file, DNS, a KV store, etc). Module owns the refresh
+// loop and the matcher swap; a source only has to answer "what are the
+// hosts right now".
+type HostSource interface {
+	// GetHosts returns the current list of hosts. It is called once on
+	// Provision and then on every tick of the refresh loop, so
+	// implementations should apply their own request timeout rather than
+	// relying on the passed-in context never being cancelled.
+	GetHosts(ctx context.Context) ([]string, error)
+}
+
+// ConditionalHostSource is an optional extension of HostSource for sources
+// that can cheaply report "nothing has changed" without re-fetching and
+// re-validating the full host list, such as an HTTP endpoint that supports
+// ETag/Last-Modified. Module prefers this over GetHosts when a source
+// implements it.
+type ConditionalHostSource interface {
+	HostSource
+
+	// GetHostsIfModified returns the current hosts, or notModified=true if
+	// nothing has changed since the last successful call. hosts is nil
+	// when notModified is true.
+	GetHostsIfModified(ctx context.Context) (hosts []string, notModified bool, err error)
+}
+
+// StatefulConditionalHostSource is an optional extension of
+// ConditionalHostSource for sources whose ETag/Last-Modified bookkeeping
+// can be round-tripped through the persisted host cache (see storage.go),
+// so the first refresh after a cold start can still send a conditional
+// request instead of unconditionally re-fetching the full list.
+type StatefulConditionalHostSource interface {
+	ConditionalHostSource
+
+	// ConditionalState returns the ETag/Last-Modified values from the most
+	// recent fetch, for persistHosts to save alongside the host list.
+	ConditionalState() (etag, lastModified string)
+
+	// SetConditionalState seeds the source's ETag/Last-Modified bookkeeping
+	// from a previously persisted cache, for loadPersistedHosts to restore
+	// on Provision.
+	SetConditionalState(etag, lastModified string)
+}
+
+// ExpressionHostSource is an optional extension of HostSource for sources
+// that can, alongside the host list, supply a CEL expression for matching
+// requests (see celmatch.go). This lets operators express routing rules
+// that host globs alone can't capture, such as requiring a specific TLS
+// SNI, header, or path prefix, without redeploying Caddy when the rules
+// change.
+type ExpressionHostSource interface {
+	HostSource
+
+	// MatchExpr returns the CEL expression from the most recently fetched
+	// hosts, or "" if none was supplied. Module calls this right after a
+	// successful GetHosts/GetHostsIfModified, so implementations only need
+	// to remember what they last parsed.
+	MatchExpr() string
+}
+
+// parseCaddyfileSource consumes a `source <name> { ... }` block and
+// dispatches the remaining tokens to the named source module's own
+// caddyfile.Unmarshaler, returning the result as a json.RawMessage suitable
+// for Module.SourceRaw. This mirrors how
+// modules/caddyhttp/reverseproxy/upstreams.go dispatches Caddyfile upstream
+// source blocks to the individual dynamic upstream source modules.
+func parseCaddyfileSource(d *caddyfile.Dispenser) (json.RawMessage, error) {
+	if !d.NextArg() {
+		return nil, d.ArgErr()
+	}
+	name := d.Val()
+
+	modID := "http.matchers.dynamic_host.sources." + name
+	modInfo, err := caddy.GetModule(modID)
+	if err != nil {
+		return nil, d.Errf("unrecognized dynamic host source '%s': %v", name, err)
+	}
+
+	inst := modInfo.New()
+
+	srcUnm, ok := inst.(caddyfile.Unmarshaler)
+	if !ok {
+		return nil, d.Errf("dynamic host source '%s' does not support Caddyfile configuration", name)
+	}
+	if err := srcUnm.UnmarshalCaddyfile(d); err != nil {
+		return nil, err
+	}
+
+	if _, ok := inst.(HostSource); !ok {
+		return nil, d.Errf("module '%s' is not a dynamic host source", modID)
+	}
+
+	return caddyconfig.JSONModuleObject(inst, "source", name, nil), nil
+}
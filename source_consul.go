@@ -0,0 +1,160 @@
+package dynamichost
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func init() {
+	caddy.RegisterModule(new(ConsulSource))
+}
+
+// ConsulSource retrieves the host list from a single key in Consul's KV
+// store, via the HTTP API's `GET /v1/kv/<key>` endpoint. The value stored
+// at the key is expected to be JSON in the same shape as HTTPSource:
+//
+//	{"hosts": ["example.com", "app1.example.com"]}
+type ConsulSource struct {
+	// Address is the base URL of the Consul HTTP API, e.g.
+	// "http://127.0.0.1:8500". Default: "http://127.0.0.1:8500".
+	Address string `json:"address,omitempty"`
+
+	// Key is the KV key holding the JSON host list.
+	Key string `json:"key,omitempty"`
+
+	// Token is the Consul ACL token to present, if required.
+	Token string `json:"token,omitempty"`
+
+	client *http.Client
+}
+
+func (ConsulSource) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.dynamic_host.sources.consul",
+		New: func() caddy.Module { return new(ConsulSource) },
+	}
+}
+
+func (s *ConsulSource) Provision(_ caddy.Context) error {
+	if s.Key == "" {
+		return fmt.Errorf("consul source: key is required")
+	}
+	if s.Address == "" {
+		s.Address = "http://127.0.0.1:8500"
+	}
+
+	s.client = &http.Client{Timeout: 5 * time.Second}
+
+	return nil
+}
+
+// consulKVEntry mirrors the subset of the Consul KV response we need.
+type consulKVEntry struct {
+	Value string `json:"Value"` // base64-encoded
+}
+
+func (s *ConsulSource) GetHosts(ctx context.Context) ([]string, error) {
+	reqURL := strings.TrimRight(s.Address, "/") + "/v1/kv/" + url.PathEscape(s.Key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("consul request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status %d for key '%s'", resp.StatusCode, s.Key)
+	}
+
+	var entries []consulKVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode consul response: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("consul key '%s' not found", s.Key)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(entries[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode consul value: %w", err)
+	}
+
+	var data struct {
+		Hosts []string `json:"hosts"`
+	}
+	if err := json.Unmarshal(value, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse consul value: %w", err)
+	}
+	if len(data.Hosts) == 0 {
+		return nil, fmt.Errorf("empty host list in consul key '%s'", s.Key)
+	}
+
+	return data.Hosts, nil
+}
+
+// UnmarshalCaddyfile sets up the source from Caddyfile tokens:
+//
+//	source consul <key> {
+//		address http://127.0.0.1:8500
+//		token <token>
+//	}
+func (s *ConsulSource) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	if d.NextArg() {
+		s.Key = d.Val()
+	}
+
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "address":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			s.Address = d.Val()
+
+		case "key":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			s.Key = d.Val()
+
+		case "token":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			s.Token = d.Val()
+
+		default:
+			return d.Errf("unrecognized parameter '%s'", d.Val())
+		}
+	}
+
+	if s.Key == "" {
+		return d.Err("key is required")
+	}
+
+	return nil
+}
+
+var (
+	_ caddy.Module          = (*ConsulSource)(nil)
+	_ caddy.Provisioner     = (*ConsulSource)(nil)
+	_ HostSource            = (*ConsulSource)(nil)
+	_ caddyfile.Unmarshaler = (*ConsulSource)(nil)
+)
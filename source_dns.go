@@ -0,0 +1,142 @@
+package dynamichost
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func init() {
+	caddy.RegisterModule(new(DNSSource))
+}
+
+// DNSSource retrieves the host list by resolving SRV or TXT records for a
+// given name at lookup time. For "srv" records, the target of each record
+// is used as a host; for "txt" records, each returned string is split on
+// whitespace/commas and used as a host list.
+type DNSSource struct {
+	// Name is the DNS name to query, e.g. "_hosts._tcp.example.com".
+	Name string `json:"name,omitempty"`
+
+	// RecordType is either "srv" or "txt". Default: "srv".
+	RecordType string `json:"record_type,omitempty"`
+
+	// Timeout bounds each DNS lookup. Default: 5s.
+	Timeout caddy.Duration `json:"timeout,omitempty"`
+
+	resolver *net.Resolver
+}
+
+func (DNSSource) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.dynamic_host.sources.dns",
+		New: func() caddy.Module { return new(DNSSource) },
+	}
+}
+
+func (s *DNSSource) Provision(_ caddy.Context) error {
+	if s.Name == "" {
+		return fmt.Errorf("dns source: name is required")
+	}
+
+	if s.RecordType == "" {
+		s.RecordType = "srv"
+	}
+	if s.RecordType != "srv" && s.RecordType != "txt" {
+		return fmt.Errorf("dns source: record_type must be 'srv' or 'txt', got '%s'", s.RecordType)
+	}
+
+	if s.Timeout == 0 {
+		s.Timeout = caddy.Duration(5 * time.Second)
+	}
+
+	s.resolver = net.DefaultResolver
+
+	return nil
+}
+
+func (s *DNSSource) GetHosts(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.Timeout))
+	defer cancel()
+
+	var hosts []string
+
+	switch s.RecordType {
+	case "srv":
+		_, addrs, err := s.resolver.LookupSRV(ctx, "", "", s.Name)
+		if err != nil {
+			return nil, fmt.Errorf("SRV lookup for '%s' failed: %w", s.Name, err)
+		}
+		for _, addr := range addrs {
+			hosts = append(hosts, strings.TrimSuffix(addr.Target, "."))
+		}
+
+	case "txt":
+		records, err := s.resolver.LookupTXT(ctx, s.Name)
+		if err != nil {
+			return nil, fmt.Errorf("TXT lookup for '%s' failed: %w", s.Name, err)
+		}
+		for _, record := range records {
+			hosts = append(hosts, strings.Fields(strings.ReplaceAll(record, ",", " "))...)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("dns source: no hosts resolved for '%s'", s.Name)
+	}
+
+	return hosts, nil
+}
+
+// UnmarshalCaddyfile sets up the source from Caddyfile tokens:
+//
+//	source dns <name> {
+//		record_type srv
+//		timeout 5s
+//	}
+func (s *DNSSource) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	if d.NextArg() {
+		s.Name = d.Val()
+	}
+
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "record_type":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			s.RecordType = d.Val()
+
+		case "timeout":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			timeout, err := caddy.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid timeout: %v", err)
+			}
+			s.Timeout = caddy.Duration(timeout)
+
+		default:
+			return d.Errf("unrecognized parameter '%s'", d.Val())
+		}
+	}
+
+	if s.Name == "" {
+		return d.Err("name is required")
+	}
+
+	return nil
+}
+
+var (
+	_ caddy.Module          = (*DNSSource)(nil)
+	_ caddy.Provisioner     = (*DNSSource)(nil)
+	_ HostSource            = (*DNSSource)(nil)
+	_ caddyfile.Unmarshaler = (*DNSSource)(nil)
+)
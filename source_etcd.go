@@ -0,0 +1,146 @@
+package dynamichost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func init() {
+	caddy.RegisterModule(new(EtcdSource))
+}
+
+// EtcdSource retrieves the host list from a single key in etcd. The value
+// stored at the key is expected to be JSON in the same shape as
+// HTTPSource: {"hosts": ["example.com", "app1.example.com"]}.
+type EtcdSource struct {
+	// Endpoints is the list of etcd cluster endpoints.
+	Endpoints []string `json:"endpoints,omitempty"`
+
+	// Key is the etcd key holding the JSON host list.
+	Key string `json:"key,omitempty"`
+
+	// DialTimeout bounds the initial connection to the cluster. Default: 5s.
+	DialTimeout caddy.Duration `json:"dial_timeout,omitempty"`
+
+	client *clientv3.Client
+}
+
+func (EtcdSource) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.dynamic_host.sources.etcd",
+		New: func() caddy.Module { return new(EtcdSource) },
+	}
+}
+
+func (s *EtcdSource) Provision(_ caddy.Context) error {
+	if s.Key == "" {
+		return fmt.Errorf("etcd source: key is required")
+	}
+	if len(s.Endpoints) == 0 {
+		return fmt.Errorf("etcd source: at least one endpoint is required")
+	}
+	if s.DialTimeout == 0 {
+		s.DialTimeout = caddy.Duration(5 * time.Second)
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   s.Endpoints,
+		DialTimeout: time.Duration(s.DialTimeout),
+	})
+	if err != nil {
+		return fmt.Errorf("etcd source: failed to connect: %w", err)
+	}
+	s.client = client
+
+	return nil
+}
+
+func (s *EtcdSource) GetHosts(ctx context.Context) ([]string, error) {
+	resp, err := s.client.Get(ctx, s.Key)
+	if err != nil {
+		return nil, fmt.Errorf("etcd get failed: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd key '%s' not found", s.Key)
+	}
+
+	var data struct {
+		Hosts []string `json:"hosts"`
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse etcd value: %w", err)
+	}
+	if len(data.Hosts) == 0 {
+		return nil, fmt.Errorf("empty host list in etcd key '%s'", s.Key)
+	}
+
+	return data.Hosts, nil
+}
+
+// Cleanup closes the etcd client connection opened in Provision, so that a
+// config reload doesn't leak the gRPC connection and its goroutines.
+func (s *EtcdSource) Cleanup() error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Close()
+}
+
+// UnmarshalCaddyfile sets up the source from Caddyfile tokens:
+//
+//	source etcd <key> {
+//		endpoints 127.0.0.1:2379 127.0.0.1:2380
+//		dial_timeout 5s
+//	}
+func (s *EtcdSource) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	if d.NextArg() {
+		s.Key = d.Val()
+	}
+
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "endpoints":
+			args := d.RemainingArgs()
+			if len(args) == 0 {
+				return d.ArgErr()
+			}
+			s.Endpoints = args
+
+		case "dial_timeout":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			timeout, err := caddy.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid dial_timeout: %v", err)
+			}
+			s.DialTimeout = caddy.Duration(timeout)
+
+		default:
+			return d.Errf("unrecognized parameter '%s'", d.Val())
+		}
+	}
+
+	if s.Key == "" {
+		return d.Err("key is required")
+	}
+	if len(s.Endpoints) == 0 {
+		return d.Err("endpoints is required")
+	}
+
+	return nil
+}
+
+var (
+	_ caddy.Module          = (*EtcdSource)(nil)
+	_ caddy.Provisioner     = (*EtcdSource)(nil)
+	_ caddy.CleanerUpper    = (*EtcdSource)(nil)
+	_ HostSource            = (*EtcdSource)(nil)
+	_ caddyfile.Unmarshaler = (*EtcdSource)(nil)
+)
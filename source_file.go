@@ -0,0 +1,181 @@
+package dynamichost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	caddy.RegisterModule(new(FileSource))
+}
+
+// FileSource retrieves the host list from a local JSON or YAML file, e.g.:
+//
+//	{"hosts": ["example.com", "app1.example.com"]}
+//
+// The file format is chosen by its extension (.yaml/.yml vs anything else,
+// which is treated as JSON). The file is re-read on every refresh tick, so
+// changes are picked up on the next tick; the fsnotify watcher only logs
+// changes and does not trigger an earlier refresh.
+//
+// The file may also include a "match" key containing a CEL expression (see
+// celmatch.go); when present, Module uses it instead of matching "hosts"
+// directly.
+type FileSource struct {
+	// Path is the path to the host list file on disk.
+	Path string `json:"path,omitempty"`
+
+	watcher *fsnotify.Watcher
+	logger  *zap.Logger
+
+	mu        sync.Mutex
+	matchExpr string
+}
+
+func (*FileSource) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.dynamic_host.sources.file",
+		New: func() caddy.Module { return new(FileSource) },
+	}
+}
+
+func (s *FileSource) Provision(ctx caddy.Context) error {
+	if s.Path == "" {
+		return fmt.Errorf("file source: path is required")
+	}
+
+	s.logger = ctx.Logger()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("file source: failed to create watcher: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(s.Path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("file source: failed to watch '%s': %w", s.Path, err)
+	}
+	s.watcher = watcher
+
+	go s.watch(ctx)
+
+	return nil
+}
+
+// watch just logs changes to the host file; GetHosts always re-reads the
+// file from disk, so the watcher's job is purely observability (and, in the
+// future, could be used to trigger an out-of-band refresh).
+func (s *FileSource) watch(ctx caddy.Context) {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(s.Path) {
+				s.logger.Debug("host file changed", zap.String("path", s.Path), zap.String("op", event.Op.String()))
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Error("file source watcher error", zap.Error(err))
+		case <-ctx.Done():
+			s.watcher.Close()
+			return
+		}
+	}
+}
+
+func (s *FileSource) GetHosts(_ context.Context) ([]string, error) {
+	contents, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host file: %w", err)
+	}
+
+	var data struct {
+		Hosts []string `json:"hosts" yaml:"hosts"`
+		Match string   `json:"match" yaml:"match"`
+	}
+
+	ext := strings.ToLower(filepath.Ext(s.Path))
+	if ext == ".yaml" || ext == ".yml" {
+		if err := yaml.Unmarshal(contents, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML host file: %w", err)
+		}
+	} else {
+		if err := json.Unmarshal(contents, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON host file: %w", err)
+		}
+	}
+
+	if len(data.Hosts) == 0 {
+		return nil, fmt.Errorf("empty host list in file")
+	}
+	for _, host := range data.Hosts {
+		if strings.TrimSpace(host) == "" {
+			return nil, fmt.Errorf("invalid empty host in list")
+		}
+	}
+
+	s.mu.Lock()
+	s.matchExpr = data.Match
+	s.mu.Unlock()
+
+	return data.Hosts, nil
+}
+
+// MatchExpr returns the CEL match expression from the most recently read
+// file, or "" if none was supplied.
+func (s *FileSource) MatchExpr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.matchExpr
+}
+
+// UnmarshalCaddyfile sets up the source from Caddyfile tokens:
+//
+//	source file <path> {
+//		path <path>
+//	}
+func (s *FileSource) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	if d.NextArg() {
+		s.Path = d.Val()
+	}
+
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "path":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			s.Path = d.Val()
+		default:
+			return d.Errf("unrecognized parameter '%s'", d.Val())
+		}
+	}
+
+	if s.Path == "" {
+		return d.Err("path is required")
+	}
+
+	return nil
+}
+
+var (
+	_ caddy.Module          = (*FileSource)(nil)
+	_ caddy.Provisioner     = (*FileSource)(nil)
+	_ HostSource            = (*FileSource)(nil)
+	_ ExpressionHostSource  = (*FileSource)(nil)
+	_ caddyfile.Unmarshaler = (*FileSource)(nil)
+)
@@ -0,0 +1,295 @@
+package dynamichost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"golang.org/x/net/http2"
+)
+
+func init() {
+	caddy.RegisterModule(new(HTTPSource))
+}
+
+// HTTPSource retrieves the host list from a JSON HTTP(S) endpoint. This is
+// the original behavior of the dynamic_host matcher, now factored out into
+// a source module. The endpoint is expected to return:
+//
+//	{"hosts": ["example.com", "app1.example.com", "*.wildcard.com"]}
+//
+// It may also include a "match" field containing a CEL expression (see
+// celmatch.go); when present, Module uses it instead of matching "hosts"
+// directly.
+type HTTPSource struct {
+	// URL is the HTTP/HTTPS endpoint that provides the JSON host list.
+	URL string `json:"url,omitempty"`
+
+	// Headers are added to every outgoing request. Values support Caddy
+	// replacer placeholders, e.g. "{env.API_TOKEN}".
+	Headers http.Header `json:"headers,omitempty"`
+
+	// BasicAuthUser and BasicAuthPass, if set, enable HTTP Basic auth.
+	BasicAuthUser string `json:"basic_auth_user,omitempty"`
+	BasicAuthPass string `json:"basic_auth_pass,omitempty"`
+
+	// TLS configures the client's TLS behavior for https:// URLs.
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// Timeout bounds each request to the source. Default: 5s.
+	Timeout caddy.Duration `json:"timeout,omitempty"`
+
+	u      *url.URL
+	client *http.Client
+	repl   *caddy.Replacer
+
+	condMu       sync.Mutex
+	etag         string
+	lastModified string
+	matchExpr    string
+}
+
+func (*HTTPSource) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.dynamic_host.sources.http",
+		New: func() caddy.Module { return new(HTTPSource) },
+	}
+}
+
+func (s *HTTPSource) Provision(_ caddy.Context) error {
+	if s.URL == "" {
+		return fmt.Errorf("http source: url is required")
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("http source: invalid url '%s': %w", s.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("http source: url must use http or https scheme, got '%s'", u.Scheme)
+	}
+	s.u = u
+
+	if (s.BasicAuthUser == "") != (s.BasicAuthPass == "") {
+		return fmt.Errorf("http source: basic_auth requires both a user and a password")
+	}
+
+	if s.Timeout == 0 {
+		s.Timeout = caddy.Duration(5 * time.Second)
+	}
+
+	transport := &http.Transport{}
+	if s.TLS != nil {
+		tlsConfig, err := s.TLS.buildTLSConfig()
+		if err != nil {
+			return fmt.Errorf("http source: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	// Configure HTTP/2 unconditionally, not just when a tls{} block is
+	// present: http.DefaultTransport (what a bare http.Client used before
+	// this source existed) negotiates HTTP/2 over TLS automatically, and a
+	// hand-built *http.Transport doesn't unless told to.
+	if err := http2.ConfigureTransport(transport); err != nil {
+		return fmt.Errorf("http source: configuring HTTP/2: %w", err)
+	}
+
+	s.client = &http.Client{
+		Timeout:   time.Duration(s.Timeout),
+		Transport: transport,
+	}
+	s.repl = caddy.NewReplacer()
+
+	return nil
+}
+
+func (s *HTTPSource) GetHosts(ctx context.Context) ([]string, error) {
+	hosts, _, err := s.GetHostsIfModified(ctx)
+	return hosts, err
+}
+
+// GetHostsIfModified fetches the host list, sending If-None-Match /
+// If-Modified-Since based on the ETag/Last-Modified headers seen on the
+// previous successful (200 OK) response, and reports notModified=true on a
+// 304 response instead of re-decoding a body.
+func (s *HTTPSource) GetHostsIfModified(ctx context.Context) ([]string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.u.String(), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Caddy-Dynamic-Host-Matcher/1.0")
+
+	for name, values := range s.Headers {
+		for _, value := range values {
+			req.Header.Add(name, s.repl.ReplaceAll(value, ""))
+		}
+	}
+	if s.BasicAuthUser != "" {
+		req.SetBasicAuth(s.repl.ReplaceAll(s.BasicAuthUser, ""), s.repl.ReplaceAll(s.BasicAuthPass, ""))
+	}
+
+	s.condMu.Lock()
+	etag, lastModified := s.etag, s.lastModified
+	s.condMu.Unlock()
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Hosts []string `json:"hosts"`
+		Match string   `json:"match"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, false, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	if len(data.Hosts) == 0 {
+		return nil, false, fmt.Errorf("empty host list returned")
+	}
+	for _, host := range data.Hosts {
+		if strings.TrimSpace(host) == "" {
+			return nil, false, fmt.Errorf("invalid empty host in list")
+		}
+	}
+
+	s.condMu.Lock()
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	s.matchExpr = data.Match
+	s.condMu.Unlock()
+
+	return data.Hosts, false, nil
+}
+
+// MatchExpr returns the CEL match expression from the most recently fetched
+// response, or "" if none was supplied.
+func (s *HTTPSource) MatchExpr() string {
+	s.condMu.Lock()
+	defer s.condMu.Unlock()
+	return s.matchExpr
+}
+
+// ConditionalState returns the ETag/Last-Modified values seen on the most
+// recent successful (200 OK) response.
+func (s *HTTPSource) ConditionalState() (etag, lastModified string) {
+	s.condMu.Lock()
+	defer s.condMu.Unlock()
+	return s.etag, s.lastModified
+}
+
+// SetConditionalState seeds the ETag/Last-Modified bookkeeping used to
+// build If-None-Match / If-Modified-Since headers, so a cache restored
+// from caddy.Storage can still send a conditional request on the first
+// fetch after a restart.
+func (s *HTTPSource) SetConditionalState(etag, lastModified string) {
+	s.condMu.Lock()
+	defer s.condMu.Unlock()
+	s.etag, s.lastModified = etag, lastModified
+}
+
+// UnmarshalCaddyfile sets up the source from Caddyfile tokens:
+//
+//	source http <url> {
+//		url <url>
+//		timeout <duration>
+//		header_up <field> <value>
+//		basic_auth <user> <pass>
+//		tls {
+//			...
+//		}
+//	}
+func (s *HTTPSource) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	if d.NextArg() {
+		s.URL = d.Val()
+	}
+
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "url":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			s.URL = d.Val()
+
+		case "timeout":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			timeout, err := caddy.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid timeout: %v", err)
+			}
+			s.Timeout = caddy.Duration(timeout)
+
+		case "header_up":
+			args := d.RemainingArgs()
+			if len(args) < 2 {
+				return d.ArgErr()
+			}
+			if s.Headers == nil {
+				s.Headers = http.Header{}
+			}
+			s.Headers.Add(args[0], strings.Join(args[1:], " "))
+
+		case "basic_auth":
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return d.ArgErr()
+			}
+			s.BasicAuthUser, s.BasicAuthPass = args[0], args[1]
+
+		case "tls":
+			s.TLS = &TLSConfig{}
+			if err := s.TLS.UnmarshalCaddyfile(d); err != nil {
+				return err
+			}
+
+		default:
+			return d.Errf("unrecognized parameter '%s'", d.Val())
+		}
+	}
+
+	if s.URL == "" {
+		return d.Err("url is required")
+	}
+	if _, err := url.Parse(s.URL); err != nil {
+		return d.Errf("invalid url: %v", err)
+	}
+
+	return nil
+}
+
+var (
+	_ caddy.Module                  = (*HTTPSource)(nil)
+	_ caddy.Provisioner             = (*HTTPSource)(nil)
+	_ HostSource                    = (*HTTPSource)(nil)
+	_ ConditionalHostSource         = (*HTTPSource)(nil)
+	_ StatefulConditionalHostSource = (*HTTPSource)(nil)
+	_ ExpressionHostSource          = (*HTTPSource)(nil)
+	_ caddyfile.Unmarshaler         = (*HTTPSource)(nil)
+)
@@ -0,0 +1,160 @@
+package dynamichost
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func TestHTTPSource_UnmarshalCaddyfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+		wantURL string
+	}{
+		{
+			name:    "inline url",
+			input:   `http https://api.example.com/hosts`,
+			wantURL: "https://api.example.com/hosts",
+		},
+		{
+			name: "block url",
+			input: `http {
+				url https://api.example.com/hosts
+			}`,
+			wantURL: "https://api.example.com/hosts",
+		},
+		{
+			name:    "missing url",
+			input:   `http`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := caddyfile.NewTestDispenser(tt.input)
+			d.Next() // consume the source name, as parseCaddyfileSource does
+			s := &HTTPSource{}
+
+			err := s.UnmarshalCaddyfile(d)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalCaddyfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && s.URL != tt.wantURL {
+				t.Errorf("URL = %v, want %v", s.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestHTTPSource_Provision(t *testing.T) {
+	s := &HTTPSource{URL: "https://api.example.com/hosts"}
+	if err := s.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if s.client == nil {
+		t.Fatal("expected client to be initialized")
+	}
+	if s.client.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", s.client.Timeout)
+	}
+}
+
+func TestHTTPSource_Provision_ConfiguresHTTP2WithoutTLSBlock(t *testing.T) {
+	s := &HTTPSource{URL: "https://api.example.com/hosts"}
+	if err := s.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	transport, ok := s.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport = %T, want *http.Transport", s.client.Transport)
+	}
+	if len(transport.TLSNextProto) == 0 {
+		t.Error("expected HTTP/2 to be configured even without a tls{} block")
+	}
+}
+
+func TestHTTPSource_Provision_InvalidScheme(t *testing.T) {
+	s := &HTTPSource{URL: "ftp://api.example.com/hosts"}
+	if err := s.Provision(caddy.Context{}); err == nil {
+		t.Fatal("expected error for invalid scheme")
+	}
+}
+
+func TestHTTPSource_Provision_UnpairedBasicAuth(t *testing.T) {
+	s := &HTTPSource{URL: "https://api.example.com/hosts", BasicAuthUser: "user"}
+	if err := s.Provision(caddy.Context{}); err == nil {
+		t.Fatal("expected error when basic_auth password is missing")
+	}
+}
+
+func TestHTTPSource_UnmarshalCaddyfile_Auth(t *testing.T) {
+	input := `http https://api.example.com/hosts {
+		header_up X-Api-Key {env.API_TOKEN}
+		basic_auth alice hunter2
+		tls {
+			insecure_skip_verify
+		}
+	}`
+
+	d := caddyfile.NewTestDispenser(input)
+	d.Next() // consume the source name, as parseCaddyfileSource does
+	s := &HTTPSource{}
+	if err := s.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("UnmarshalCaddyfile() error = %v", err)
+	}
+
+	if got := s.Headers.Get("X-Api-Key"); got != "{env.API_TOKEN}" {
+		t.Errorf("X-Api-Key header = %v, want {env.API_TOKEN}", got)
+	}
+	if s.BasicAuthUser != "alice" || s.BasicAuthPass != "hunter2" {
+		t.Errorf("basic auth = %v/%v, want alice/hunter2", s.BasicAuthUser, s.BasicAuthPass)
+	}
+	if s.TLS == nil || !s.TLS.InsecureSkipVerify {
+		t.Error("expected tls.insecure_skip_verify to be true")
+	}
+}
+
+func TestHTTPSource_GetHosts_SendsAuthAndHeaders(t *testing.T) {
+	os.Setenv("DYNAMIC_HOST_TEST_TOKEN", "secret-token")
+	defer os.Unsetenv("DYNAMIC_HOST_TEST_TOKEN")
+
+	var gotHeader, gotUser, gotPass string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Api-Key")
+		gotUser, gotPass, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hosts": ["example.com"]}`))
+	}))
+	defer server.Close()
+
+	s := &HTTPSource{
+		URL:           server.URL,
+		Headers:       http.Header{"X-Api-Key": []string{"{env.DYNAMIC_HOST_TEST_TOKEN}"}},
+		BasicAuthUser: "alice",
+		BasicAuthPass: "hunter2",
+	}
+	if err := s.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	if _, err := s.GetHosts(context.Background()); err != nil {
+		t.Fatalf("GetHosts() error = %v", err)
+	}
+
+	if gotHeader != "secret-token" {
+		t.Errorf("X-Api-Key header = %v, want secret-token", gotHeader)
+	}
+	if gotUser != "alice" || gotPass != "hunter2" {
+		t.Errorf("basic auth = %v/%v, want alice/hunter2", gotUser, gotPass)
+	}
+}
@@ -0,0 +1,139 @@
+package dynamichost
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/google/cel-go/cel"
+	"go.uber.org/zap"
+)
+
+// cachedHostList is the JSON shape written to and read from caddy.Storage.
+// ETag and LastModified are only populated for sources implementing
+// StatefulConditionalHostSource, and let the first refresh after a restart
+// send a conditional request instead of unconditionally re-fetching the
+// full list. Match is only populated for sources implementing
+// ExpressionHostSource; without it, seeding m.matchExpr from cache would
+// silently drop the CEL constraint until the first refresh and fail open.
+type cachedHostList struct {
+	Hosts        []string  `json:"hosts"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Match        string    `json:"match,omitempty"`
+}
+
+// cacheKeyFor derives the storage key for a given source configuration, so
+// that distinct dynamic_host instances don't clobber each other's cache.
+func cacheKeyFor(sourceRaw json.RawMessage) string {
+	sum := sha256.Sum256(sourceRaw)
+	return "dynamic_host/" + hex.EncodeToString(sum[:]) + ".json"
+}
+
+// persistEnabled reports whether persistence is on, which defaults to true
+// unless explicitly disabled with `persist false`.
+func (m *Module) persistEnabled() bool {
+	return m.Persist == nil || *m.Persist
+}
+
+// persistHosts writes the current host list to storage, if persistence is
+// enabled and the context has storage configured. Errors are logged, not
+// returned, since a failure to persist should never take the matcher down.
+func (m *Module) persistHosts(hosts []string) {
+	if !m.persistEnabled() || m.storage == nil {
+		return
+	}
+
+	record := cachedHostList{Hosts: hosts, FetchedAt: time.Now()}
+	if sc, ok := m.source.(StatefulConditionalHostSource); ok {
+		record.ETag, record.LastModified = sc.ConditionalState()
+	}
+	if es, ok := m.source.(ExpressionHostSource); ok {
+		record.Match = es.MatchExpr()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		m.logger.Warn("failed to marshal host cache", zap.Error(err))
+		return
+	}
+
+	if err := m.storage.Store(m.ctx, m.cacheKey, data); err != nil {
+		m.logger.Warn("failed to persist host cache", zap.Error(err))
+	}
+}
+
+// loadPersistedHosts seeds m.matcher from the last persisted host list, if
+// persistence is enabled, storage is configured, a cache entry exists, and
+// it isn't older than MaxCacheAge. This lets Caddy start serving
+// immediately after a restart even if the source is unreachable at boot.
+func (m *Module) loadPersistedHosts(ctx caddy.Context) error {
+	storage := ctx.Storage()
+	if storage == nil {
+		return nil
+	}
+	m.storage = storage
+
+	exists := storage.Exists(ctx, m.cacheKey)
+	if !exists {
+		return nil
+	}
+
+	data, err := storage.Load(ctx, m.cacheKey)
+	if err != nil {
+		return fmt.Errorf("loading persisted host cache: %w", err)
+	}
+
+	var record cachedHostList
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("parsing persisted host cache: %w", err)
+	}
+
+	maxAge := time.Duration(m.MaxCacheAge)
+	if maxAge > 0 && time.Since(record.FetchedAt) > maxAge {
+		m.logger.Info("discarding stale persisted host cache",
+			zap.Duration("age", time.Since(record.FetchedAt)),
+			zap.Duration("max_cache_age", maxAge))
+		return nil
+	}
+	if len(record.Hosts) == 0 {
+		return nil
+	}
+
+	// Compile the persisted match expression, if any, before seeding
+	// anything. If it no longer compiles, refuse to seed from this cache
+	// entry at all rather than seed the host list without its CEL
+	// constraint, which would fail open until the next refresh.
+	var matchExpr cel.Program
+	if record.Match != "" {
+		var err error
+		matchExpr, err = compileMatchExpr(record.Match)
+		if err != nil {
+			return fmt.Errorf("compiling persisted match expression: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.matcher = record.Hosts
+	if err := m.matcher.Provision(m.ctx); err != nil {
+		return fmt.Errorf("provisioning matcher from persisted host cache: %w", err)
+	}
+	m.lastSuccess = record.FetchedAt
+	m.matchExpr = matchExpr
+
+	if sc, ok := m.source.(StatefulConditionalHostSource); ok {
+		sc.SetConditionalState(record.ETag, record.LastModified)
+	}
+
+	m.logger.Info("seeded host list from persisted cache",
+		zap.Int("count", len(record.Hosts)),
+		zap.Time("fetched_at", record.FetchedAt))
+
+	return nil
+}
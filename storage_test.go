@@ -0,0 +1,205 @@
+package dynamichost
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/certmagic"
+	"go.uber.org/zap"
+)
+
+// memStorage is a minimal in-memory certmagic.Storage for tests.
+type memStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{data: map[string][]byte{}}
+}
+
+func (s *memStorage) Lock(_ context.Context, _ string) error   { return nil }
+func (s *memStorage) Unlock(_ context.Context, _ string) error { return nil }
+
+func (s *memStorage) Store(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *memStorage) Load(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return v, nil
+}
+
+func (s *memStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memStorage) Exists(_ context.Context, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data[key]
+	return ok
+}
+
+func (s *memStorage) List(_ context.Context, _ string, _ bool) ([]string, error) {
+	return nil, nil
+}
+
+func (s *memStorage) Stat(_ context.Context, key string) (certmagic.KeyInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	if !ok {
+		return certmagic.KeyInfo{}, fs.ErrNotExist
+	}
+	return certmagic.KeyInfo{Key: key, Size: int64(len(v))}, nil
+}
+
+func TestPersistAndLoadHosts(t *testing.T) {
+	storage := newMemStorage()
+
+	m := &Module{
+		logger:   zap.NewNop(),
+		storage:  storage,
+		cacheKey: cacheKeyFor([]byte(`{"source":"http"}`)),
+		ctx:      caddy.Context{},
+	}
+
+	m.persistHosts([]string{"example.com", "test.com"})
+
+	if !storage.Exists(context.Background(), m.cacheKey) {
+		t.Fatal("expected cache key to exist after persistHosts")
+	}
+
+	// loadPersistedHosts pulls storage from ctx.Storage(), which a bare
+	// caddy.Context{} doesn't provide in tests, so exercise the read path
+	// directly against our fake storage instead.
+	data, err := storage.Load(context.Background(), m.cacheKey)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var record cachedHostList
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("unmarshal error = %v", err)
+	}
+	if len(record.Hosts) != 2 {
+		t.Errorf("persisted hosts = %v, want 2 entries", record.Hosts)
+	}
+}
+
+func TestPersistAndLoadHosts_RoundTripsConditionalState(t *testing.T) {
+	storage := newMemStorage()
+	source := &HTTPSource{}
+
+	m := &Module{
+		logger:   zap.NewNop(),
+		storage:  storage,
+		source:   source,
+		cacheKey: cacheKeyFor([]byte(`{"source":"http"}`)),
+		ctx:      caddy.Context{},
+	}
+
+	source.SetConditionalState(`"v1"`, "Wed, 21 Oct 2026 07:28:00 GMT")
+	m.persistHosts([]string{"example.com"})
+
+	data, err := storage.Load(context.Background(), m.cacheKey)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var record cachedHostList
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("unmarshal error = %v", err)
+	}
+	if record.ETag != `"v1"` {
+		t.Errorf("persisted etag = %q, want %q", record.ETag, `"v1"`)
+	}
+
+	restored := &HTTPSource{}
+	m.source = restored
+	if sc, ok := m.source.(StatefulConditionalHostSource); ok {
+		sc.SetConditionalState(record.ETag, record.LastModified)
+	}
+
+	etag, lastModified := restored.ConditionalState()
+	if etag != `"v1"` || lastModified != "Wed, 21 Oct 2026 07:28:00 GMT" {
+		t.Errorf("restored conditional state = (%q, %q), want (%q, %q)",
+			etag, lastModified, `"v1"`, "Wed, 21 Oct 2026 07:28:00 GMT")
+	}
+}
+
+func TestPersistAndLoadHosts_RoundTripsMatchExpr(t *testing.T) {
+	storage := newMemStorage()
+	source := &HTTPSource{}
+	source.matchExpr = `req.host.endsWith(".tenant.example")`
+
+	m := &Module{
+		logger:   zap.NewNop(),
+		storage:  storage,
+		source:   source,
+		cacheKey: cacheKeyFor([]byte(`{"source":"http"}`)),
+		ctx:      caddy.Context{},
+	}
+
+	m.persistHosts([]string{"acme.tenant.example"})
+
+	data, err := storage.Load(context.Background(), m.cacheKey)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var record cachedHostList
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("unmarshal error = %v", err)
+	}
+	if record.Match != source.matchExpr {
+		t.Fatalf("persisted match = %q, want %q", record.Match, source.matchExpr)
+	}
+
+	// loadPersistedHosts compiles record.Match the same way; exercise that
+	// step directly since a bare caddy.Context{} has no Storage() to drive
+	// loadPersistedHosts end-to-end (see TestPersistAndLoadHosts above).
+	if _, err := compileMatchExpr(record.Match); err != nil {
+		t.Errorf("persisted match expression failed to compile: %v", err)
+	}
+}
+
+func TestPersistEnabled(t *testing.T) {
+	m := &Module{}
+	if !m.persistEnabled() {
+		t.Error("expected persist to default to true")
+	}
+
+	off := false
+	m.Persist = &off
+	if m.persistEnabled() {
+		t.Error("expected persist to be disabled when explicitly set false")
+	}
+}
+
+func TestCacheKeyFor_Stable(t *testing.T) {
+	a := cacheKeyFor([]byte(`{"source":"http","url":"https://example.com"}`))
+	b := cacheKeyFor([]byte(`{"source":"http","url":"https://example.com"}`))
+	c := cacheKeyFor([]byte(`{"source":"file","path":"/etc/hosts.json"}`))
+
+	if a != b {
+		t.Error("expected identical source config to produce the same cache key")
+	}
+	if a == c {
+		t.Error("expected different source config to produce different cache keys")
+	}
+}
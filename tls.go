@@ -0,0 +1,115 @@
+package dynamichost
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+// TLSConfig configures the TLS behavior of a dynamic host source's HTTP
+// client: pinning a CA, presenting a client certificate, or overriding SNI
+// verification. It covers the subset of options operators most commonly
+// need to reach an internal control plane that gates its hosts endpoint
+// behind mTLS.
+type TLSConfig struct {
+	// CAFile, if set, is used instead of the system trust store to verify
+	// the source's certificate.
+	CAFile string `json:"ca_file,omitempty"`
+
+	// ClientCertificateFile and ClientCertificateKeyFile, if both set,
+	// present a client certificate to the source (mutual TLS).
+	ClientCertificateFile    string `json:"client_certificate_file,omitempty"`
+	ClientCertificateKeyFile string `json:"client_certificate_key_file,omitempty"`
+
+	// ServerName overrides the SNI/verification hostname sent to the
+	// source, e.g. when the URL uses an IP address.
+	ServerName string `json:"server_name,omitempty"`
+
+	// InsecureSkipVerify disables certificate verification. Only use this
+	// for local development.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// buildTLSConfig turns t into a *tls.Config, loading the CA and client
+// certificate from disk as needed.
+func (t *TLSConfig) buildTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pemBytes, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("no certificates found in ca_file '%s'", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.ClientCertificateFile != "" || t.ClientCertificateKeyFile != "" {
+		if t.ClientCertificateFile == "" || t.ClientCertificateKeyFile == "" {
+			return nil, fmt.Errorf("both client_certificate_file and client_certificate_key_file are required")
+		}
+		cert, err := tls.LoadX509KeyPair(t.ClientCertificateFile, t.ClientCertificateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// UnmarshalCaddyfile sets up the TLS config from a `tls { ... }` block:
+//
+//	tls {
+//		ca_file <path>
+//		client_certificate_file <path>
+//		client_certificate_key_file <path>
+//		server_name <name>
+//		insecure_skip_verify
+//	}
+func (t *TLSConfig) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "ca_file":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			t.CAFile = d.Val()
+
+		case "client_certificate_file":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			t.ClientCertificateFile = d.Val()
+
+		case "client_certificate_key_file":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			t.ClientCertificateKeyFile = d.Val()
+
+		case "server_name":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			t.ServerName = d.Val()
+
+		case "insecure_skip_verify":
+			t.InsecureSkipVerify = true
+
+		default:
+			return d.Errf("unrecognized tls parameter '%s'", d.Val())
+		}
+	}
+
+	return nil
+}